@@ -11,10 +11,10 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -22,7 +22,7 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-const baseURL = "https://sp.cargo-avto.ru/catalog/"
+const defaultCargoAvtoBaseURL = "https://sp.cargo-avto.ru/catalog/"
 
 // Config содержит параметры для обработки.
 type Config struct {
@@ -34,54 +34,125 @@ type Config struct {
 	DBName            string  // DBName (for example, "ue.db")
 	VendorCodePattern string  // VendorCodePattern (for example, "^box_\d+_\d+$")
 	UsePcs            bool    // UsePcs (for example, true)
+
+	// Supplier выбирает, какой SupplierScraper из Scrapers обслуживает этот запуск
+	// (например, "cargo-avto"). Пусто — используется "cargo-avto".
+	Supplier string
+	// Scrapers - поставщики данных о товаре, ключ - SupplierScraper.Name(). Если nil,
+	// используется один CargoAvtoScraper по умолчанию.
+	Scrapers map[string]SupplierScraper
+
+	// Workers - размер пула воркеров, параллельно обрабатывающих карточки (каждый держит свою
+	// вкладку браузера). По умолчанию 1 - карточки обрабатываются последовательно, как раньше.
+	Workers int
+	// SupplierRateLimit - сколько запросов в секунду разрешено отправлять на сайт поставщика
+	// (по умолчанию defaultSupplierRPS).
+	SupplierRateLimit float64
+
+	// WarehouseFilter - точное имя склада, тариф которого нужно использовать (например,
+	// "Маркетплейс"). Пусто и SelectTariff не задан - используется склад "Маркетплейс", как
+	// раньше.
+	WarehouseFilter string
+	// WarehouseIDs - список ID складов-кандидатов; берётся первый найденный среди них тариф.
+	// Проверяется перед WarehouseFilter, если оба заданы.
+	WarehouseIDs []int
+	// SelectTariff выбирает один тариф из полного списка складов. Если задан, имеет приоритет
+	// над WarehouseFilter и WarehouseIDs - используется, когда выбор склада зависит от более
+	// сложной логики, чем имя или ID.
+	SelectTariff func([]WarehouseTariff) WarehouseTariff
+	// TariffDate - дата в формате YYYY-MM-DD, на которую запрашиваются тарифы FBS. Пусто -
+	// берётся сегодняшняя дата.
+	TariffDate string
+
+	// CardFilters - дополнительные условия отбора карточек, проверяемые после VendorCodePattern
+	// и объединяемые между собой через И. Пусто - фильтрация по CardFilters не применяется.
+	CardFilters []CardFilter
+	// OnMultiSKU определяет, что делать с карточкой, у которой SKU отсутствует или их больше
+	// одного. Пусто - как OnMultiSKUSkip.
+	OnMultiSKU OnMultiSKUPolicy
 }
 
 func Process(apiKey string, cfg Config) error {
-	// 1. Получаем тарифы FBS
-	base, liter, err := getFBSTariffs(apiKey)
+	ctx := context.Background()
+
+	// 1. Получаем тарифы FBS по всем складам и выбираем нужный
+	tariffDate := cfg.TariffDate
+	if tariffDate == "" {
+		tariffDate = time.Now().Format("2006-01-02")
+	}
+	var warehouses []WarehouseTariff
+	err := retryWithBackoff(ctx, func() error {
+		var tariffErr error
+		warehouses, tariffErr = ListFBSTariffs(apiKey, tariffDate)
+		return tariffErr
+	})
 	if err != nil {
 		return fmt.Errorf("ошибка получения тарифов: %v", err)
 	}
-	log.Printf("Тарифы FBS: base=%.2f, liter=%.2f", base, liter)
-
-	// 2. Удаляем старую базу данных, если существует, и открываем новую
-	if err := os.Remove(cfg.DBName); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("ошибка удаления старой базы данных: %v", err)
+	tariff, err := selectWarehouseTariff(warehouses, cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка выбора склада: %v", err)
 	}
-	log.Println("Старая база данных удалена.")
+	base, liter := tariff.BoxDeliveryBase, tariff.BoxDeliveryLiter
+	log.Printf("Тарифы FBS (склад %q): base=%.2f, liter=%.2f", tariff.Name, base, liter)
 
+	// 2. Открываем базу данных и применяем миграции (старые данные и история цен сохраняются
+	// между запусками — база больше не пересоздаётся с нуля).
 	db, err := sql.Open("sqlite", cfg.DBName)
 	if err != nil {
 		return fmt.Errorf("ошибка при открытии базы данных: %v", err)
 	}
 	defer db.Close()
 
-	createTable(db)
+	if err := Migrate(db); err != nil {
+		return fmt.Errorf("ошибка применения миграций: %v", err)
+	}
 
 	// 3. Загружаем карточки, используя переданные objectIDs
-	allCards := fetchAllCards(apiKey, cfg.ObjectIDs)
+	allCards := fetchAllCards(ctx, apiKey, cfg.ObjectIDs)
 	log.Printf("Всего загружено %d карточек.", len(allCards))
 
 	// 4. Настраиваем Chromedp для парсинга страниц
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", false),
 		chromedp.Flag("disable-gpu", true),
 	)
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, allocOpts...)
 	defer allocCancel()
 
-	ctx, ctxCancel := chromedp.NewContext(allocCtx)
-	defer ctxCancel()
+	scrapers := cfg.Scrapers
+	if scrapers == nil {
+		scrapers = map[string]SupplierScraper{
+			"cargo-avto": NewCargoAvtoScraper(defaultCargoAvtoBaseURL),
+		}
+	}
+	supplierName := cfg.Supplier
+	if supplierName == "" {
+		supplierName = "cargo-avto"
+	}
+	scraper, ok := scrapers[supplierName]
+	if !ok {
+		return fmt.Errorf("неизвестный поставщик %q: нет SupplierScraper с таким именем", supplierName)
+	}
+	limiters := buildRateLimiters(scrapers, cfg.SupplierRateLimit)
 
 	// 5. Загружаем цены товаров
-	prices, err := getProductPrices(apiKey, 1000, 0, 0)
-	if err != nil {
+	var prices []Product
+	if err := retryWithBackoff(ctx, func() error {
+		var pricesErr error
+		prices, pricesErr = getProductPrices(apiKey, 1000, 0, 0)
+		return pricesErr
+	}); err != nil {
 		log.Printf("Ошибка получения цен: %v", err)
 	}
 
 	// 6. Загружаем комиссии
-	commissions, err := getCommission(apiKey)
-	if err != nil {
+	var commissions []Commission
+	if err := retryWithBackoff(ctx, func() error {
+		var commissionsErr error
+		commissions, commissionsErr = getCommission(apiKey)
+		return commissionsErr
+	}); err != nil {
 		log.Printf("Ошибка получения комиссии: %v", err)
 	}
 
@@ -94,18 +165,32 @@ func Process(apiKey string, cfg Config) error {
 	}
 	log.Println("Комиссия:", commissionRate)
 
-	productDataCache := make(map[string]map[string]string)
 	skuMap := extractSKUs(allCards)
 	vendorCodePattern := regexp.MustCompile(cfg.VendorCodePattern)
-	// 7. Обрабатываем каждую карточку
+
+	// 7. Готовим джобы для пула воркеров
+	var jobs []cardJob
 	for _, card := range allCards {
 		if !vendorCodePattern.MatchString(card.VendorCode) {
 			log.Printf("Пропускаем товар с некорректным VendorCode: %s", card.VendorCode)
 			continue
 		}
+		matched, err := matchCardFilters(card, cfg.CardFilters)
+		if err != nil {
+			return fmt.Errorf("ошибка применения CardFilters к товару %s: %w", card.VendorCode, err)
+		}
+		if !matched {
+			continue
+		}
+
 		skus := skuMap[card.NmID]
-		if len(skus) != 1 {
-			panic(fmt.Sprintf("SKU либо отсутствует, либо их больше 1 для товара с VendorCode: %s", card.VendorCode))
+		sku, err := resolveSKU(card.VendorCode, skus, cfg.OnMultiSKU)
+		if err != nil {
+			if cfg.OnMultiSKU == OnMultiSKUError {
+				return fmt.Errorf("ошибка обработки товара %s: %w", card.VendorCode, err)
+			}
+			log.Printf("Пропускаем товар %s: %v", card.VendorCode, err)
+			continue
 		}
 
 		// Извлекаем productID и pcs из vendorCode
@@ -122,81 +207,22 @@ func Process(apiKey string, cfg Config) error {
 			}
 		}
 
-		var (
-			wbPrice           float64
-			wbDiscountedPrice float64
-			wbClubDiscounted  float64
-		)
-		for _, p := range prices {
-			if p.VendorCode == card.VendorCode {
-				if len(p.Sizes) > 0 {
-					wbPrice = p.Sizes[0].Price
-					wbDiscountedPrice = p.Sizes[0].DiscountedPrice
-					wbClubDiscounted = p.Sizes[0].ClubDiscountedPrice
-				}
-				break
-			}
-		}
-
-		// Парсинг данных товара (с кешированием)
-		var productData map[string]string
-		if cachedData, exists := productDataCache[productID]; exists {
-			log.Printf("Используем кешированные данные для товара: %s", productID)
-			productData = cachedData
-		} else {
-			log.Printf("Парсим страницу для товара: %s", productID)
-			url := baseURL + productID + "/"
-			productData, err = scrapeProductData(ctx, url)
-			if err != nil {
-				log.Printf("Ошибка при обработке товара %s: %v", productID, err)
-				continue
-			}
-			productDataCache[productID] = productData
-		}
-
-		// Рассчитываем стоимость с учетом количества pcs
-		cost, err := convertAndMultiply(productData["price"], fmt.Sprintf("%d", pcsInt))
-		if err != nil {
-			log.Printf("Ошибка при конвертации и умножении для %s: %v", productID, err)
-			continue
-		}
+		jobs = append(jobs, cardJob{card: card, productID: productID, pcsInt: pcsInt, sku: sku})
+	}
 
-		// Рассчитываем тариф
-		volumeInLiters := CalculateVolumeLiters(card.Dimensions.Width, card.Dimensions.Height, card.Dimensions.Length)
-		tariff := CalculateTariff(volumeInLiters, base, liter)
-		fmt.Printf("volumeInLiters: %f, base: %f, liter: %f, tariff: %f\n", volumeInLiters, base, liter, tariff)
-
-		// Рассчитываем комиссию (используем clubDiscountPrice)
-		returns := (tariff + 50) / 9
-		fixedCosts := cost + int(math.Ceil(tariff)) + cfg.Delivery + cfg.PVZ + int(math.Ceil(returns))
-		fmt.Printf("fixedCosts: %d (cost: %d, tariff: %f, delivery: %d, pvz: %d, returns: %f)\n", fixedCosts, cost, tariff, cfg.Delivery, cfg.PVZ, returns)
-		comNum := (float64(commissionRate) + 1) / 100
-		okPrice, err := CalcPrice(cfg.DesiredMargin, cfg.TaxRate, comNum, float64(fixedCosts))
-		if err != nil {
-			log.Printf("Ошибка при расчете цены: %v", err)
-			continue
-		}
-		commission := int(okPrice * comNum)
-
-		// Сохраняем данные в базу
-		saveToDatabase(db, SaveParams{
-			NmID:              card.NmID,
-			VendorCode:        card.VendorCode,
-			Width:             card.Dimensions.Width,
-			Height:            card.Dimensions.Height,
-			Length:            card.Dimensions.Length,
-			Pcs:               pcsInt,
-			ProductID:         productID,
-			WbPrice:           wbPrice,
-			WbDiscountedPrice: wbDiscountedPrice,
-			WbClubDiscounted:  wbClubDiscounted,
-			AvailableCountStr: productData["availableCount"],
-			Cost:              cost,
-			Tariff:            tariff,
-			Commission:        commission,
-			OKPrice:           okPrice,
-		}, skus[0])
+	// 8. Обрабатываем карточки пулом воркеров и последовательно пишем результаты в базу
+	deps := pipelineDeps{
+		cfg:        cfg,
+		base:       base,
+		liter:      liter,
+		warehouse:  tariff.Name,
+		prices:     prices,
+		commission: commissionRate,
+		scraper:    scraper,
+		limiter:    limiters[supplierName],
+		cache:      &sync.Map{},
 	}
+	runPipeline(allocCtx, deps, db, jobs)
 
 	log.Println("Обработка завершена.")
 	return nil
@@ -204,75 +230,6 @@ func Process(apiKey string, cfg Config) error {
 
 // ----------------------- Вспомогательные типы и функции -----------------------
 
-type TariffResponse struct {
-	Response struct {
-		Data struct {
-			WarehouseList []struct {
-				WarehouseName    string          `json:"warehouseName"`
-				BoxDeliveryBase  json.RawMessage `json:"boxDeliveryBase"`
-				BoxDeliveryLiter json.RawMessage `json:"boxDeliveryLiter"`
-			} `json:"warehouseList"`
-		} `json:"data"`
-	} `json:"response"`
-}
-
-func parseFloat(raw json.RawMessage) (float64, error) {
-	var num float64
-	if err := json.Unmarshal(raw, &num); err == nil {
-		return num, nil
-	}
-	var str string
-	if err := json.Unmarshal(raw, &str); err == nil {
-		str = strings.ReplaceAll(str, ",", ".")
-		return strconv.ParseFloat(str, 64)
-	}
-	return 0, fmt.Errorf("не удалось преобразовать значение в float64")
-}
-
-func getFBSTariffs(apiKey string) (float64, float64, error) {
-	url := "https://common-api.wildberries.ru/api/v1/tariffs/box"
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, 0, err
-	}
-	req.Header.Set("Authorization", apiKey)
-
-	q := req.URL.Query()
-	q.Add("date", "2025-02-01")
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	var data TariffResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return 0, 0, err
-	}
-
-	for _, warehouse := range data.Response.Data.WarehouseList {
-		if warehouse.WarehouseName == "Маркетплейс" {
-			base, err1 := parseFloat(warehouse.BoxDeliveryBase)
-			liter, err2 := parseFloat(warehouse.BoxDeliveryLiter)
-			if err1 != nil || err2 != nil {
-				return 0, 0, fmt.Errorf("ошибка конвертации тарифов: %v, %v", err1, err2)
-			}
-			return base, liter, nil
-		}
-	}
-
-	return 0, 0, fmt.Errorf("не найден склад 'Маркетплейс'")
-}
-
 type CardsListResponse struct {
 	Cards  []Card `json:"cards"`
 	Cursor struct {
@@ -286,6 +243,7 @@ type Card struct {
 	NmID       int           `json:"nmID"`
 	VendorCode string        `json:"vendorCode"`
 	Title      string        `json:"title"`
+	SubjectID  int           `json:"subjectID"`
 	UpdatedAt  string        `json:"updatedAt"`
 	Dimensions Dimensions    `json:"dimensions"`
 	Sizes      []ProductSize `json:"sizes"`
@@ -466,13 +424,18 @@ func getCardsList(apiKey string, updatedAt string, nmID int, objectIDs []int) (*
 	return &response, nil
 }
 
-func fetchAllCards(apiKey string, objectIDs []int) []Card {
+func fetchAllCards(ctx context.Context, apiKey string, objectIDs []int) []Card {
 	var allCards []Card
 	var updatedAt string
 	var nmID int
 
 	for {
-		response, err := getCardsList(apiKey, updatedAt, nmID, objectIDs)
+		var response *CardsListResponse
+		err := retryWithBackoff(ctx, func() error {
+			var reqErr error
+			response, reqErr = getCardsList(apiKey, updatedAt, nmID, objectIDs)
+			return reqErr
+		})
 		if err != nil {
 			log.Printf("Ошибка запроса карточек: %v", err)
 			break
@@ -493,78 +456,6 @@ func fetchAllCards(apiKey string, objectIDs []int) []Card {
 	return allCards
 }
 
-func scrapeProductData(ctx context.Context, url string) (map[string]string, error) {
-	var productPrice string
-	var availableStoresCount int
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.Sleep(2*time.Second),
-		chromedp.Click(`li.tabs-item a[href="#samovivoz-tabs"]`, chromedp.ByQuery),
-		chromedp.Sleep(2*time.Second),
-		chromedp.Text(`li[data-min="1"] .price-val`, &productPrice, chromedp.ByQuery),
-		chromedp.Evaluate(`document.querySelectorAll('.avail-item-status.avail').length`, &availableStoresCount),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга страницы %s: %w", url, err)
-	}
-
-	productPrice = strings.TrimSpace(productPrice)
-	productPrice = strings.ReplaceAll(productPrice, "p", "")
-	productPrice = strings.ReplaceAll(productPrice, " ", "")
-
-	return map[string]string{
-		"price":          productPrice,
-		"availableCount": fmt.Sprintf("%d", availableStoresCount),
-	}, nil
-}
-
-func convertAndMultiply(priceStr, multiplierStr string) (int, error) {
-	price, err := strconv.ParseFloat(priceStr, 64)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка преобразования price: %v", err)
-	}
-	roundedPrice := int(math.Ceil(price))
-
-	multiplier, err := strconv.Atoi(multiplierStr)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка преобразования multiplier: %v", err)
-	}
-	return roundedPrice * multiplier, nil
-}
-
-func createTable(db *sql.DB) {
-	query := `
-	CREATE TABLE IF NOT EXISTS products (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		nm_id INTEGER,
-		vendor_code TEXT,
-		width INTEGER,
-		height INTEGER,
-		length INTEGER,
-		pcs INTEGER,
-		product_id TEXT,
-		skus TEXT,
-		price REAL,
-		discounted_price REAL,
-		club_discounted_price REAL,
-		available_count INTEGER,
-		cost INTEGER,
-		tariff REAL,
-		commission INTEGER,
-		ok_price REAL,
-		new_price INTEGER,
-		new_discount INTEGER,
-		UNIQUE (product_id, pcs)
-	);
-	`
-	_, err := db.Exec(query)
-	if err != nil {
-		log.Fatalf("Ошибка при создании таблицы: %v", err)
-	}
-	log.Println("Таблица products проверена/создана.")
-}
-
 // SaveParams используется для передачи параметров в функцию сохранения.
 type SaveParams struct {
 	NmID                  int
@@ -578,20 +469,15 @@ type SaveParams struct {
 	WbDiscountedPrice float64
 	WbClubDiscounted  float64
 
-	AvailableCountStr string
-	Cost              int
-	Tariff            float64
-	Commission        int
-	OKPrice           float64
+	AvailableCount int
+	Cost           int
+	Tariff         float64
+	Commission     int
+	OKPrice        float64
+	Warehouse      string
 }
 
 func saveToDatabase(db *sql.DB, params SaveParams, sku string) {
-	availableCount, err := strconv.Atoi(params.AvailableCountStr)
-	if err != nil {
-		log.Printf("Ошибка при конвертации availableCount для %s: %v", params.ProductID, err)
-		availableCount = 0
-	}
-
 	newPrice, newDiscount := calculateNewPriceAndDiscount(params.OKPrice)
 
 	query := `
@@ -600,9 +486,9 @@ INSERT INTO products (
 	width, height, length,
 	pcs, product_id, skus,
 	price, discounted_price, club_discounted_price,
-	available_count, cost, tariff, commission, ok_price,
+	available_count, cost, tariff, commission, ok_price, warehouse,
 	new_price, new_discount
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(product_id, pcs) DO UPDATE SET
 	nm_id = excluded.nm_id,
 	vendor_code = excluded.vendor_code,
@@ -617,23 +503,25 @@ ON CONFLICT(product_id, pcs) DO UPDATE SET
 	tariff = excluded.tariff,
 	commission = excluded.commission,
 	ok_price = excluded.ok_price,
+	warehouse = excluded.warehouse,
 	new_price = excluded.new_price,
 	new_discount = excluded.new_discount,
 	skus = excluded.skus;
 `
-	_, err = db.Exec(query,
+	_, err := db.Exec(query,
 		params.NmID, params.VendorCode,
 		params.Width, params.Height, params.Length,
 		params.Pcs, params.ProductID, sku,
 		params.WbPrice, params.WbDiscountedPrice, params.WbClubDiscounted,
-		availableCount, params.Cost, params.Tariff, params.Commission, params.OKPrice,
+		params.AvailableCount, params.Cost, params.Tariff, params.Commission, params.OKPrice, params.Warehouse,
 		newPrice, newDiscount,
 	)
 	if err != nil {
 		log.Printf("Ошибка при сохранении данных для %s: %v", params.ProductID, err)
-	} else {
-		log.Printf("Данные для товара %s успешно сохранены. SKUs: %s", params.ProductID, sku)
+		return
 	}
+	log.Printf("Данные для товара %s успешно сохранены. SKUs: %s", params.ProductID, sku)
+	recordPriceHistory(db, params, newPrice, newDiscount)
 }
 
 func CalcPrice(desiredMargin, taxRate, commissionRate, fixedCosts float64) (float64, error) {
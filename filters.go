@@ -0,0 +1,195 @@
+package cargo_avto_update
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CardFilterField - поле карточки, по которому можно фильтровать.
+type CardFilterField string
+
+const (
+	CardFilterFieldVendorCode CardFilterField = "vendorCode"
+	CardFilterFieldNmID       CardFilterField = "nmID"
+	CardFilterFieldTitle      CardFilterField = "title"
+	CardFilterFieldWidth      CardFilterField = "width"
+	CardFilterFieldHeight     CardFilterField = "height"
+	CardFilterFieldLength     CardFilterField = "length"
+	CardFilterFieldVolume     CardFilterField = "volume"
+	CardFilterFieldSubjectID  CardFilterField = "subjectID"
+)
+
+// CardFilterType - способ сравнения значения поля с CardFilter.Value.
+type CardFilterType string
+
+const (
+	CardFilterEquals    CardFilterType = "EQUALS"
+	CardFilterTermMatch CardFilterType = "TERM_MATCH"
+	CardFilterRegex     CardFilterType = "REGEX"
+	CardFilterRange     CardFilterType = "RANGE"
+	CardFilterIn        CardFilterType = "IN"
+	CardFilterNotIn     CardFilterType = "NOT_IN"
+)
+
+// RangeValue - границы CardFilter с Type=RANGE, обе включительно.
+type RangeValue struct {
+	Min float64
+	Max float64
+}
+
+// CardFilter - одно условие отбора карточек. Value интерпретируется в зависимости от Type:
+// строка или число для EQUALS, строка для TERM_MATCH/REGEX (только для строковых полей),
+// RangeValue для RANGE, []interface{} для IN/NOT_IN. Несколько CardFilter в Config.CardFilters объединяются через И.
+type CardFilter struct {
+	Field CardFilterField
+	Type  CardFilterType
+	Value interface{}
+}
+
+// matchCardFilters возвращает true, если карточка удовлетворяет всем filters. Пустой filters
+// пропускает любую карточку, сохраняя поведение без фильтрации.
+func matchCardFilters(card Card, filters []CardFilter) (bool, error) {
+	for _, f := range filters {
+		ok, err := matchCardFilter(card, f)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func matchCardFilter(card Card, f CardFilter) (bool, error) {
+	value, err := cardFilterValue(card, f.Field)
+	if err != nil {
+		return false, err
+	}
+
+	switch f.Type {
+	case CardFilterEquals:
+		return equalsFilterValue(value, f.Value), nil
+	case CardFilterTermMatch:
+		s, ok := value.(string)
+		term, okTerm := f.Value.(string)
+		if !ok || !okTerm {
+			return false, fmt.Errorf("TERM_MATCH требует строковое значение для поля %s", f.Field)
+		}
+		return strings.Contains(strings.ToLower(s), strings.ToLower(term)), nil
+	case CardFilterRegex:
+		s, ok := value.(string)
+		pattern, okPattern := f.Value.(string)
+		if !ok || !okPattern {
+			return false, fmt.Errorf("REGEX требует строковое значение для поля %s", f.Field)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("некорректное регулярное выражение %q: %w", pattern, err)
+		}
+		return re.MatchString(s), nil
+	case CardFilterRange:
+		num, ok := toFloat(value)
+		rng, okRange := f.Value.(RangeValue)
+		if !ok || !okRange {
+			return false, fmt.Errorf("RANGE требует числовое поле и RangeValue для %s", f.Field)
+		}
+		return num >= rng.Min && num <= rng.Max, nil
+	case CardFilterIn, CardFilterNotIn:
+		list, ok := f.Value.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s требует список значений для поля %s", f.Type, f.Field)
+		}
+		found := containsFilterValue(list, value)
+		if f.Type == CardFilterIn {
+			return found, nil
+		}
+		return !found, nil
+	default:
+		return false, fmt.Errorf("неизвестный тип фильтра: %s", f.Type)
+	}
+}
+
+func cardFilterValue(card Card, field CardFilterField) (interface{}, error) {
+	switch field {
+	case CardFilterFieldVendorCode:
+		return card.VendorCode, nil
+	case CardFilterFieldNmID:
+		return float64(card.NmID), nil
+	case CardFilterFieldTitle:
+		return card.Title, nil
+	case CardFilterFieldWidth:
+		return float64(card.Dimensions.Width), nil
+	case CardFilterFieldHeight:
+		return float64(card.Dimensions.Height), nil
+	case CardFilterFieldLength:
+		return float64(card.Dimensions.Length), nil
+	case CardFilterFieldVolume:
+		return CalculateVolumeLiters(card.Dimensions.Width, card.Dimensions.Height, card.Dimensions.Length), nil
+	case CardFilterFieldSubjectID:
+		return float64(card.SubjectID), nil
+	default:
+		return nil, fmt.Errorf("неизвестное поле фильтра: %s", field)
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func equalsFilterValue(value, filterValue interface{}) bool {
+	if vs, ok := value.(string); ok {
+		fs, ok := filterValue.(string)
+		return ok && vs == fs
+	}
+	vn, ok1 := toFloat(value)
+	fn, ok2 := toFloat(filterValue)
+	return ok1 && ok2 && vn == fn
+}
+
+func containsFilterValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if equalsFilterValue(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnMultiSKUPolicy определяет, что делать с карточкой, у которой SKU отсутствует или их больше
+// одного.
+type OnMultiSKUPolicy string
+
+const (
+	// OnMultiSKUSkip пропускает карточку с логом - поведение по умолчанию (нулевое значение).
+	OnMultiSKUSkip OnMultiSKUPolicy = "skip"
+	// OnMultiSKUPickFirst берёт первый SKU из списка, если их больше одного.
+	OnMultiSKUPickFirst OnMultiSKUPolicy = "pickFirst"
+	// OnMultiSKUError останавливает Process с ошибкой вместо пропуска карточки.
+	OnMultiSKUError OnMultiSKUPolicy = "error"
+)
+
+// resolveSKU возвращает единственный SKU карточки vendorCode согласно policy. Если skus не
+// ровно один, поведение зависит от policy: pickFirst берёт первый элемент, если SKU больше
+// одного; во всех остальных случаях (в том числе при отсутствии SKU) возвращается ошибка -
+// вызывающий код сам решает, пропустить карточку или остановить Process.
+func resolveSKU(vendorCode string, skus []string, policy OnMultiSKUPolicy) (string, error) {
+	if len(skus) == 1 {
+		return skus[0], nil
+	}
+	if policy == OnMultiSKUPickFirst && len(skus) > 1 {
+		return skus[0], nil
+	}
+	return "", fmt.Errorf("у товара с VendorCode %s SKU либо отсутствует, либо их больше 1 (найдено %d)", vendorCode, len(skus))
+}
@@ -0,0 +1,173 @@
+package cargo_avto_update
+
+import "testing"
+
+func testCard() Card {
+	return Card{
+		NmID:       12345,
+		VendorCode: "ACME-Widget-01",
+		Title:      "Чёрный виджет",
+		SubjectID:  77,
+		Dimensions: Dimensions{Width: 10, Height: 20, Length: 30, IsValid: true},
+	}
+}
+
+func TestMatchCardFilterEquals(t *testing.T) {
+	card := testCard()
+
+	ok, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldVendorCode, Type: CardFilterEquals, Value: "ACME-Widget-01"})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = matchCardFilter(card, CardFilter{Field: CardFilterFieldSubjectID, Type: CardFilterEquals, Value: float64(77)})
+	if err != nil || !ok {
+		t.Fatalf("expected match, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = matchCardFilter(card, CardFilter{Field: CardFilterFieldVendorCode, Type: CardFilterEquals, Value: "other"})
+	if err != nil || ok {
+		t.Fatalf("expected no match, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMatchCardFilterTermMatch(t *testing.T) {
+	card := testCard()
+
+	ok, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldTitle, Type: CardFilterTermMatch, Value: "виджет"})
+	if err != nil || !ok {
+		t.Fatalf("expected term match, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = matchCardFilter(card, CardFilter{Field: CardFilterFieldTitle, Type: CardFilterTermMatch, Value: "гаджет"})
+	if err != nil || ok {
+		t.Fatalf("expected no term match, got ok=%v err=%v", ok, err)
+	}
+
+	if _, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldNmID, Type: CardFilterTermMatch, Value: "123"}); err == nil {
+		t.Fatal("expected error for non-string field with TERM_MATCH")
+	}
+}
+
+func TestMatchCardFilterRegex(t *testing.T) {
+	card := testCard()
+
+	ok, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldVendorCode, Type: CardFilterRegex, Value: `^ACME-.*-\d+$`})
+	if err != nil || !ok {
+		t.Fatalf("expected regex match, got ok=%v err=%v", ok, err)
+	}
+
+	if _, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldVendorCode, Type: CardFilterRegex, Value: `[`}); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestMatchCardFilterRange(t *testing.T) {
+	card := testCard()
+
+	ok, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldWidth, Type: CardFilterRange, Value: RangeValue{Min: 5, Max: 15}})
+	if err != nil || !ok {
+		t.Fatalf("expected in-range match, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = matchCardFilter(card, CardFilter{Field: CardFilterFieldWidth, Type: CardFilterRange, Value: RangeValue{Min: 11, Max: 15}})
+	if err != nil || ok {
+		t.Fatalf("expected out-of-range non-match, got ok=%v err=%v", ok, err)
+	}
+
+	if _, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldWidth, Type: CardFilterRange, Value: "not-a-range"}); err == nil {
+		t.Fatal("expected error for non-RangeValue RANGE filter")
+	}
+}
+
+func TestMatchCardFilterInNotIn(t *testing.T) {
+	card := testCard()
+	list := []interface{}{float64(5), float64(99)}
+
+	ok, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldSubjectID, Type: CardFilterIn, Value: []interface{}{float64(77), float64(1)}})
+	if err != nil || !ok {
+		t.Fatalf("expected IN match, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = matchCardFilter(card, CardFilter{Field: CardFilterFieldWidth, Type: CardFilterNotIn, Value: list})
+	if err != nil || !ok {
+		t.Fatalf("expected NOT_IN match (10 not in list of width values), got ok=%v err=%v", ok, err)
+	}
+
+	if _, err := matchCardFilter(card, CardFilter{Field: CardFilterFieldSubjectID, Type: CardFilterIn, Value: "not-a-list"}); err == nil {
+		t.Fatal("expected error for non-list IN filter")
+	}
+}
+
+func TestMatchCardFiltersEmptyPassesEverything(t *testing.T) {
+	ok, err := matchCardFilters(testCard(), nil)
+	if err != nil || !ok {
+		t.Fatalf("expected empty filters to pass, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestMatchCardFiltersAllMustMatch(t *testing.T) {
+	card := testCard()
+	filters := []CardFilter{
+		{Field: CardFilterFieldSubjectID, Type: CardFilterEquals, Value: float64(77)},
+		{Field: CardFilterFieldWidth, Type: CardFilterRange, Value: RangeValue{Min: 0, Max: 5}},
+	}
+
+	ok, err := matchCardFilters(card, filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected AND of filters to reject when one filter fails")
+	}
+}
+
+func TestMatchCardFilterUnknownField(t *testing.T) {
+	if _, err := matchCardFilter(testCard(), CardFilter{Field: "bogus", Type: CardFilterEquals, Value: "x"}); err == nil {
+		t.Fatal("expected error for unknown filter field")
+	}
+}
+
+func TestMatchCardFilterUnknownType(t *testing.T) {
+	if _, err := matchCardFilter(testCard(), CardFilter{Field: CardFilterFieldVendorCode, Type: "BOGUS", Value: "x"}); err == nil {
+		t.Fatal("expected error for unknown filter type")
+	}
+}
+
+func TestResolveSKUSingle(t *testing.T) {
+	sku, err := resolveSKU("vc1", []string{"sku-1"}, OnMultiSKUSkip)
+	if err != nil || sku != "sku-1" {
+		t.Fatalf("expected sku-1, got %q err=%v", sku, err)
+	}
+}
+
+func TestResolveSKUSkipPolicyErrorsOnMultiple(t *testing.T) {
+	if _, err := resolveSKU("vc1", []string{"sku-1", "sku-2"}, OnMultiSKUSkip); err == nil {
+		t.Fatal("expected error for multiple SKUs under skip policy")
+	}
+}
+
+func TestResolveSKUSkipPolicyErrorsOnNone(t *testing.T) {
+	if _, err := resolveSKU("vc1", nil, OnMultiSKUSkip); err == nil {
+		t.Fatal("expected error for no SKUs under skip policy")
+	}
+}
+
+func TestResolveSKUPickFirst(t *testing.T) {
+	sku, err := resolveSKU("vc1", []string{"sku-1", "sku-2"}, OnMultiSKUPickFirst)
+	if err != nil || sku != "sku-1" {
+		t.Fatalf("expected sku-1, got %q err=%v", sku, err)
+	}
+}
+
+func TestResolveSKUPickFirstStillErrorsOnNone(t *testing.T) {
+	if _, err := resolveSKU("vc1", nil, OnMultiSKUPickFirst); err == nil {
+		t.Fatal("expected error for no SKUs even under pickFirst policy")
+	}
+}
+
+func TestResolveSKUErrorPolicy(t *testing.T) {
+	if _, err := resolveSKU("vc1", []string{"sku-1", "sku-2"}, OnMultiSKUError); err == nil {
+		t.Fatal("expected error for multiple SKUs under error policy")
+	}
+}
@@ -0,0 +1,197 @@
+package cargo_avto_update
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationsDir — поддиректория embed.FS, в которой лежат файлы миграций.
+const migrationsDir = "migrations"
+
+// migration описывает один файл миграции, упорядоченный по числовому префиксу имени.
+type migration struct {
+	version  int
+	name     string
+	checksum string
+	sql      string
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationsFS, migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог миграций: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		version, err := parseMigrationVersion(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("некорректное имя файла миграции %q: %w", entry.Name(), err)
+		}
+		content, err := migrationsFS.ReadFile(migrationsDir + "/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать миграцию %q: %w", entry.Name(), err)
+		}
+		sum := sha256.Sum256(content)
+		migrations = append(migrations, migration{
+			version:  version,
+			name:     entry.Name(),
+			checksum: hex.EncodeToString(sum[:]),
+			sql:      string(content),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func parseMigrationVersion(fileName string) (int, error) {
+	prefix, _, ok := strings.Cut(fileName, "_")
+	if !ok {
+		return 0, fmt.Errorf("ожидается формат NNNN_description.sql")
+	}
+	return strconv.Atoi(prefix)
+}
+
+// Migrate применяет все ещё не применённые миграции из migrations/ к базе данных в порядке
+// возрастания версии. Каждая применённая миграция фиксируется в таблице schema_migrations
+// вместе с контрольной суммой содержимого файла; при расхождении контрольных сумм или если
+// предыдущий запуск оставил миграцию в состоянии dirty, Migrate возвращает ошибку и не
+// применяет ничего дальше — это должно блокировать запуск до ручного разбирательства.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL,
+			dirty INTEGER NOT NULL DEFAULT 0
+		);
+	`); err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var checksum string
+		var dirty bool
+		err := db.QueryRow(`SELECT checksum, dirty FROM schema_migrations WHERE version = ?`, m.version).Scan(&checksum, &dirty)
+		switch {
+		case err == sql.ErrNoRows:
+			if applyErr := applyMigration(db, m); applyErr != nil {
+				return applyErr
+			}
+		case err != nil:
+			return fmt.Errorf("ошибка чтения schema_migrations для версии %d: %w", m.version, err)
+		case dirty:
+			return fmt.Errorf("миграция %s (версия %d) помечена как dirty — предыдущий запуск завершился ошибкой, требуется ручное вмешательство", m.name, m.version)
+		case checksum != m.checksum:
+			return fmt.Errorf("контрольная сумма миграции %s (версия %d) изменилась с момента применения — файл был отредактирован задним числом", m.name, m.version)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	if _, err := db.Exec(
+		`INSERT INTO schema_migrations (version, name, checksum, applied_at, dirty) VALUES (?, ?, ?, ?, 1)`,
+		m.version, m.name, m.checksum, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("не удалось зарезервировать миграцию %s: %w", m.name, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию для миграции %s: %w", m.name, err)
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("миграция %s завершилась ошибкой и помечена как dirty: %w", m.name, err)
+	}
+
+	if _, err := tx.Exec(`UPDATE schema_migrations SET dirty = 0 WHERE version = ?`, m.version); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("не удалось снять флаг dirty для миграции %s: %w", m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать миграцию %s: %w", m.name, err)
+	}
+
+	return nil
+}
+
+// PriceHistoryEntry — одна запись из price_history: срез состояния расчёта цены на момент
+// конкретного запуска Process.
+type PriceHistoryEntry struct {
+	NmID        int
+	ProductID   string
+	Pcs         int
+	OKPrice     float64
+	NewPrice    int
+	NewDiscount int
+	Cost        int
+	Tariff      float64
+	Commission  int
+	ScrapedAt   time.Time
+}
+
+// History возвращает историю изменений цены для товара с указанным nmID, отсортированную от
+// самой новой записи к самой старой — по ней можно проследить дрейф цены между запусками или
+// откатиться к предыдущему решению.
+func History(db *sql.DB, nmID int) ([]PriceHistoryEntry, error) {
+	rows, err := db.Query(`
+		SELECT nm_id, product_id, pcs, ok_price, new_price, new_discount, cost, tariff, commission, scraped_at
+		FROM price_history
+		WHERE nm_id = ?
+		ORDER BY scraped_at DESC
+	`, nmID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса истории цен для nmID=%d: %w", nmID, err)
+	}
+	defer rows.Close()
+
+	var entries []PriceHistoryEntry
+	for rows.Next() {
+		var e PriceHistoryEntry
+		if err := rows.Scan(&e.NmID, &e.ProductID, &e.Pcs, &e.OKPrice, &e.NewPrice, &e.NewDiscount, &e.Cost, &e.Tariff, &e.Commission, &e.ScrapedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения строки истории цен для nmID=%d: %w", nmID, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func recordPriceHistory(db *sql.DB, params SaveParams, newPrice, newDiscount int) {
+	_, err := db.Exec(`
+		INSERT INTO price_history (nm_id, product_id, pcs, ok_price, new_price, new_discount, cost, tariff, commission, scraped_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, params.NmID, params.ProductID, params.Pcs, params.OKPrice, newPrice, newDiscount, params.Cost, params.Tariff, params.Commission, time.Now().UTC())
+	if err != nil {
+		log.Printf("Ошибка при записи истории цены для %s: %v", params.ProductID, err)
+	}
+}
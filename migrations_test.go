@@ -0,0 +1,112 @@
+package cargo_avto_update
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLoadMigrationsOrderedByVersion(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected at least one migration")
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i-1].version >= migrations[i].version {
+			t.Fatalf("migrations not sorted by version: %d before %d", migrations[i-1].version, migrations[i].version)
+		}
+	}
+}
+
+func TestParseMigrationVersion(t *testing.T) {
+	v, err := parseMigrationVersion("0003_create_price_upload_attempts.sql")
+	if err != nil || v != 3 {
+		t.Fatalf("expected version 3, got %d err=%v", v, err)
+	}
+
+	if _, err := parseMigrationVersion("create_products.sql"); err == nil {
+		t.Fatal("expected error for file name without NNNN_ prefix")
+	}
+
+	if _, err := parseMigrationVersion("abcd_create_products.sql"); err == nil {
+		t.Fatal("expected error for non-numeric prefix")
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateAppliesAllMigrations(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE dirty = 0`).Scan(&count); err != nil {
+		t.Fatalf("failed to count applied migrations: %v", err)
+	}
+	if count != len(migrations) {
+		t.Fatalf("expected %d clean applied migrations, got %d", len(migrations), count)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("first Migrate failed: %v", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second Migrate should be a no-op, got error: %v", err)
+	}
+}
+
+func TestMigrateRejectsDirtyMigration(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	if _, err := db.Exec(`UPDATE schema_migrations SET dirty = 1 WHERE version = 1`); err != nil {
+		t.Fatalf("failed to mark migration dirty: %v", err)
+	}
+
+	if err := Migrate(db); err == nil {
+		t.Fatal("expected Migrate to fail on a dirty migration")
+	}
+}
+
+func TestMigrateRejectsChecksumMismatch(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'tampered' WHERE version = 1`); err != nil {
+		t.Fatalf("failed to tamper with checksum: %v", err)
+	}
+
+	if err := Migrate(db); err == nil {
+		t.Fatal("expected Migrate to fail on checksum mismatch")
+	}
+}
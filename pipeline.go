@@ -0,0 +1,212 @@
+package cargo_avto_update
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"golang.org/x/exp/rand"
+	"golang.org/x/time/rate"
+)
+
+// defaultSupplierRPS - лимит запросов в секунду к сайту поставщика по умолчанию, если
+// cfg.SupplierRateLimit не задан.
+const defaultSupplierRPS = 2
+
+// retryAttempts - число попыток для retry-обёртки над сетевыми вызовами WB и скрапером.
+const retryAttempts = 3
+
+// retryWithBackoff повторяет fn до retryAttempts раз с экспоненциальной задержкой и джиттером,
+// прекращая попытки раньше при успехе или отмене ctx.
+func retryWithBackoff(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < retryAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == retryAttempts-1 {
+			break
+		}
+		backoff := time.Duration(1<<uint(attempt)) * 200 * time.Millisecond
+		jitter := time.Duration(rand.Int63n(int64(100 * time.Millisecond)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff + jitter):
+		}
+	}
+	return err
+}
+
+// buildRateLimiters создаёт по одному rate.Limiter на каждого поставщика из scrapers, чтобы
+// каждый сайт опрашивался не чаще rps запросов в секунду.
+func buildRateLimiters(scrapers map[string]SupplierScraper, rps float64) map[string]*rate.Limiter {
+	if rps <= 0 {
+		rps = defaultSupplierRPS
+	}
+	limiters := make(map[string]*rate.Limiter, len(scrapers))
+	for name := range scrapers {
+		limiters[name] = rate.NewLimiter(rate.Limit(rps), 1)
+	}
+	return limiters
+}
+
+// cardJob - карточка, подготовленная к обработке воркером пула.
+type cardJob struct {
+	card      Card
+	productID string
+	pcsInt    int
+	sku       string
+}
+
+// pipelineDeps - неизменяемое окружение, которое нужно воркеру для обработки одной карточки:
+// общие для всех карточек тарифы, цены и комиссии, плюс скрапер и его лимитер запросов.
+type pipelineDeps struct {
+	cfg        Config
+	base       float64
+	liter      float64
+	warehouse  string
+	prices     []Product
+	commission int
+	scraper    SupplierScraper
+	limiter    *rate.Limiter
+	cache      *sync.Map // productID -> ProductData
+}
+
+// writeJob - результат обработки одной карточки, готовый к записи в базу.
+type writeJob struct {
+	params SaveParams
+	sku    string
+}
+
+// runPipeline разбирает cards на джобы, запускает cfg.Workers воркеров (каждый - свой
+// chromedp-контекст, разделяющий общий allocator), и сериализует запись результатов в db через
+// отдельную горутину-писатель, чтобы SQLite получал только последовательные Exec.
+func runPipeline(allocCtx context.Context, deps pipelineDeps, db *sql.DB, cards []cardJob) {
+	workers := deps.cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	jobs := make(chan cardJob)
+	results := make(chan writeJob)
+
+	var writerWG sync.WaitGroup
+	writerWG.Add(1)
+	go func() {
+		defer writerWG.Done()
+		for res := range results {
+			saveToDatabase(db, res.params, res.sku)
+		}
+	}()
+
+	var workersWG sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerCtx, workerCancel := chromedp.NewContext(allocCtx)
+		workersWG.Add(1)
+		go func(ctx context.Context, cancel context.CancelFunc) {
+			defer cancel()
+			defer workersWG.Done()
+			for job := range jobs {
+				params, ok := processCard(ctx, deps, job)
+				if !ok {
+					continue
+				}
+				results <- writeJob{params: params, sku: job.sku}
+			}
+		}(workerCtx, workerCancel)
+	}
+
+	for _, job := range cards {
+		jobs <- job
+	}
+	close(jobs)
+
+	workersWG.Wait()
+	close(results)
+	writerWG.Wait()
+}
+
+// processCard выполняет парсинг и расчёт цены для одной карточки. Возвращает ok=false, если
+// карточку нужно пропустить (ошибка скрапинга или расчёта уже залогирована).
+func processCard(ctx context.Context, deps pipelineDeps, job cardJob) (SaveParams, bool) {
+	card := job.card
+
+	var wbPrice, wbDiscountedPrice, wbClubDiscounted float64
+	for _, p := range deps.prices {
+		if p.VendorCode == card.VendorCode {
+			if len(p.Sizes) > 0 {
+				wbPrice = p.Sizes[0].Price
+				wbDiscountedPrice = p.Sizes[0].DiscountedPrice
+				wbClubDiscounted = p.Sizes[0].ClubDiscountedPrice
+			}
+			break
+		}
+	}
+
+	var productData ProductData
+	if cached, exists := deps.cache.Load(job.productID); exists {
+		log.Printf("Используем кешированные данные для товара: %s", job.productID)
+		productData = cached.(ProductData)
+	} else {
+		log.Printf("Парсим страницу для товара: %s (поставщик: %s)", job.productID, deps.scraper.Name())
+		if err := deps.limiter.Wait(ctx); err != nil {
+			log.Printf("Ошибка ожидания лимитера для товара %s: %v", job.productID, err)
+			return SaveParams{}, false
+		}
+		err := retryWithBackoff(ctx, func() error {
+			data, fetchErr := deps.scraper.Fetch(ctx, job.productID)
+			if fetchErr != nil {
+				return fetchErr
+			}
+			productData = data
+			return nil
+		})
+		if err != nil {
+			log.Printf("Ошибка при обработке товара %s: %v", job.productID, err)
+			return SaveParams{}, false
+		}
+		deps.cache.Store(job.productID, productData)
+	}
+
+	cost := scaledCost(productData.Price, job.pcsInt)
+
+	volumeInLiters := CalculateVolumeLiters(card.Dimensions.Width, card.Dimensions.Height, card.Dimensions.Length)
+	tariff := CalculateTariff(volumeInLiters, deps.base, deps.liter)
+	fmt.Printf("volumeInLiters: %f, base: %f, liter: %f, tariff: %f\n", volumeInLiters, deps.base, deps.liter, tariff)
+
+	returns := (tariff + 50) / 9
+	fixedCosts := cost + int(math.Ceil(tariff)) + deps.cfg.Delivery + deps.cfg.PVZ + int(math.Ceil(returns))
+	fmt.Printf("fixedCosts: %d (cost: %d, tariff: %f, delivery: %d, pvz: %d, returns: %f)\n", fixedCosts, cost, tariff, deps.cfg.Delivery, deps.cfg.PVZ, returns)
+	comNum := (float64(deps.commission) + 1) / 100
+	okPrice, err := CalcPrice(deps.cfg.DesiredMargin, deps.cfg.TaxRate, comNum, float64(fixedCosts))
+	if err != nil {
+		log.Printf("Ошибка при расчете цены: %v", err)
+		return SaveParams{}, false
+	}
+	commission := int(okPrice * comNum)
+
+	return SaveParams{
+		NmID:              card.NmID,
+		VendorCode:        card.VendorCode,
+		Width:             card.Dimensions.Width,
+		Height:            card.Dimensions.Height,
+		Length:            card.Dimensions.Length,
+		Pcs:               job.pcsInt,
+		ProductID:         job.productID,
+		WbPrice:           wbPrice,
+		WbDiscountedPrice: wbDiscountedPrice,
+		WbClubDiscounted:  wbClubDiscounted,
+		AvailableCount:    productData.AvailableStores,
+		Cost:              cost,
+		Tariff:            tariff,
+		Commission:        commission,
+		OKPrice:           okPrice,
+		Warehouse:         deps.warehouse,
+	}, true
+}
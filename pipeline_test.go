@@ -0,0 +1,97 @@
+package cargo_avto_update
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRetryWithBackoffSucceedsWithoutRetrying(t *testing.T) {
+	calls := 0
+	err := retryWithBackoff(context.Background(), func() error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call on immediate success, got %d", calls)
+	}
+}
+
+func TestRetryWithBackoffRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := retryWithBackoff(context.Background(), func() error {
+		calls++
+		if calls < retryAttempts {
+			return errors.New("transient failure")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != retryAttempts {
+		t.Fatalf("expected %d calls before success, got %d", retryAttempts, calls)
+	}
+}
+
+func TestRetryWithBackoffGivesUpAfterRetryAttempts(t *testing.T) {
+	calls := 0
+	wantErr := errors.New("persistent failure")
+	err := retryWithBackoff(context.Background(), func() error {
+		calls++
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected the last error to be returned, got %v", err)
+	}
+	if calls != retryAttempts {
+		t.Fatalf("expected exactly %d calls, got %d", retryAttempts, calls)
+	}
+}
+
+func TestRetryWithBackoffStopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retryWithBackoff(ctx, func() error {
+		calls++
+		if calls == 1 {
+			cancel()
+		}
+		return errors.New("transient failure")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBuildRateLimitersOnePerScraper(t *testing.T) {
+	scrapers := map[string]SupplierScraper{
+		"a": NewHTTPScraper("a", "http://a.example/", ".price", ".stock"),
+		"b": NewHTTPScraper("b", "http://b.example/", ".price", ".stock"),
+	}
+
+	limiters := buildRateLimiters(scrapers, 5)
+	if len(limiters) != len(scrapers) {
+		t.Fatalf("expected %d limiters, got %d", len(scrapers), len(limiters))
+	}
+	for name := range scrapers {
+		if limiters[name] == nil {
+			t.Fatalf("expected a limiter for supplier %q", name)
+		}
+		if got := float64(limiters[name].Limit()); got != 5 {
+			t.Fatalf("limiter for %q = %v rps, want 5", name, got)
+		}
+	}
+}
+
+func TestBuildRateLimitersDefaultsWhenRPSNotPositive(t *testing.T) {
+	scrapers := map[string]SupplierScraper{"a": NewHTTPScraper("a", "http://a.example/", ".price", ".stock")}
+
+	limiters := buildRateLimiters(scrapers, 0)
+	if got := float64(limiters["a"].Limit()); got != defaultSupplierRPS {
+		t.Fatalf("limiter rps = %v, want default %v", got, defaultSupplierRPS)
+	}
+}
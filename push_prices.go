@@ -0,0 +1,300 @@
+package cargo_avto_update
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// wbUploadBatchLimit - максимальное число позиций в одном запросе к WB discounts-prices API.
+const wbUploadBatchLimit = 1000
+
+const (
+	wbUploadTaskURL   = "https://discounts-prices-api.wildberries.ru/api/v2/upload/task"
+	wbUploadStatusURL = "https://discounts-prices-api.wildberries.ru/api/v2/history/tasks"
+)
+
+// PushOptions настраивает поведение PushPrices.
+type PushOptions struct {
+	// DryRun - если true, PushPrices только печатает диф между текущей ценой на WB и
+	// рассчитанной, ничего не отправляя.
+	DryRun bool
+	// MaxDeltaPct - порог изменения цены в процентах; позиции, у которых new_price отличается
+	// от текущей discounted_price сильнее, без Force не отправляются.
+	MaxDeltaPct float64
+	// Force отключает проверку MaxDeltaPct.
+	Force bool
+	// PollInterval - пауза между опросами статуса задачи (по умолчанию 3 секунды).
+	PollInterval time.Duration
+	// PollAttempts - сколько раз опрашивать статус задачи, прежде чем сдаться (по умолчанию 10).
+	PollAttempts int
+}
+
+// priceUpdate - одна позиция, ожидающая выгрузки в WB.
+type priceUpdate struct {
+	NmID        int
+	VendorCode  string
+	OldPrice    float64
+	NewPrice    int
+	NewDiscount int
+}
+
+// PushPrices отправляет рассчитанные new_price/new_discount из products в Wildberries через
+// discounts-prices API, батчами по wbUploadBatchLimit позиций, опрашивает статус загруженной
+// задачи и фиксирует результат по каждому nm_id в price_upload_attempts. Повторный вызов после
+// частичного сбоя переотправляет только те nm_id, для которых ещё нет успешной попытки с
+// текущими new_price/new_discount.
+func PushPrices(ctx context.Context, apiKey string, db *sql.DB, opts PushOptions) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 3 * time.Second
+	}
+	if opts.PollAttempts <= 0 {
+		opts.PollAttempts = 10
+	}
+
+	pending, err := pendingPriceUpdates(db)
+	if err != nil {
+		return fmt.Errorf("ошибка выборки позиций для выгрузки: %w", err)
+	}
+	log.Printf("К выгрузке в WB: %d позиций.", len(pending))
+
+	if opts.DryRun {
+		for _, p := range pending {
+			deltaPct := priceDeltaPct(p.OldPrice, p.NewPrice)
+			log.Printf("[dry-run] nmID=%d vendorCode=%s: %.2f -> %d (скидка %d%%, изменение %.1f%%)",
+				p.NmID, p.VendorCode, p.OldPrice, p.NewPrice, p.NewDiscount, deltaPct)
+		}
+		return nil
+	}
+
+	toUpload := make([]priceUpdate, 0, len(pending))
+	for _, p := range pending {
+		deltaPct := priceDeltaPct(p.OldPrice, p.NewPrice)
+		if !opts.Force && opts.MaxDeltaPct > 0 && deltaPct > opts.MaxDeltaPct {
+			log.Printf("Пропускаем nmID=%d: изменение цены %.1f%% превышает MaxDeltaPct=%.1f%%", p.NmID, deltaPct, opts.MaxDeltaPct)
+			recordUploadAttempt(db, p, 0, false, fmt.Sprintf("изменение цены %.1f%% превышает MaxDeltaPct=%.1f%%", deltaPct, opts.MaxDeltaPct))
+			continue
+		}
+		toUpload = append(toUpload, p)
+	}
+
+	for start := 0; start < len(toUpload); start += wbUploadBatchLimit {
+		end := start + wbUploadBatchLimit
+		if end > len(toUpload) {
+			end = len(toUpload)
+		}
+		if err := pushBatch(ctx, apiKey, db, toUpload[start:end], opts); err != nil {
+			return fmt.Errorf("ошибка выгрузки батча [%d:%d]: %w", start, end, err)
+		}
+	}
+
+	return nil
+}
+
+func priceDeltaPct(oldPrice float64, newPrice int) float64 {
+	if oldPrice == 0 {
+		return 100
+	}
+	return math.Abs(float64(newPrice)-oldPrice) / oldPrice * 100
+}
+
+func pendingPriceUpdates(db *sql.DB) ([]priceUpdate, error) {
+	rows, err := db.Query(`
+		SELECT p.nm_id, p.vendor_code, p.discounted_price, p.new_price, p.new_discount
+		FROM products p
+		LEFT JOIN (
+			SELECT nm_id, new_price, new_discount, success,
+			       ROW_NUMBER() OVER (PARTITION BY nm_id ORDER BY attempted_at DESC) AS rn
+			FROM price_upload_attempts
+		) a ON a.nm_id = p.nm_id AND a.rn = 1
+		WHERE a.nm_id IS NULL
+		   OR a.success = 0
+		   OR a.new_price != p.new_price
+		   OR a.new_discount != p.new_discount
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []priceUpdate
+	for rows.Next() {
+		var u priceUpdate
+		if err := rows.Scan(&u.NmID, &u.VendorCode, &u.OldPrice, &u.NewPrice, &u.NewDiscount); err != nil {
+			return nil, err
+		}
+		updates = append(updates, u)
+	}
+	return updates, rows.Err()
+}
+
+type wbUploadTaskItem struct {
+	NmID     int `json:"nmID"`
+	Price    int `json:"price"`
+	Discount int `json:"discount"`
+}
+
+type wbUploadTaskRequest struct {
+	Data []wbUploadTaskItem `json:"data"`
+}
+
+type wbUploadTaskResponse struct {
+	Data struct {
+		UploadID int64 `json:"uploadID"`
+	} `json:"data"`
+}
+
+type wbUploadStatusResponse struct {
+	Data struct {
+		UploadID int64  `json:"uploadID"`
+		Status   string `json:"status"`
+		Errors   []struct {
+			NmID  int    `json:"nmID"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	} `json:"data"`
+}
+
+func pushBatch(ctx context.Context, apiKey string, db *sql.DB, batch []priceUpdate, opts PushOptions) error {
+	reqBody := wbUploadTaskRequest{Data: make([]wbUploadTaskItem, len(batch))}
+	for i, u := range batch {
+		reqBody.Data[i] = wbUploadTaskItem{NmID: u.NmID, Price: u.NewPrice, Discount: u.NewDiscount}
+	}
+
+	uploadID, err := submitUploadTask(ctx, apiKey, reqBody)
+	if err != nil {
+		for _, u := range batch {
+			recordUploadAttempt(db, u, 0, false, err.Error())
+		}
+		return err
+	}
+
+	status, err := pollUploadStatus(ctx, apiKey, uploadID, opts.PollInterval, opts.PollAttempts)
+	if err != nil {
+		for _, u := range batch {
+			recordUploadAttempt(db, u, uploadID, false, err.Error())
+		}
+		return err
+	}
+
+	failed := make(map[int]string, len(status.Data.Errors))
+	for _, e := range status.Data.Errors {
+		failed[e.NmID] = e.Error
+	}
+	for _, u := range batch {
+		if errMsg, isFailed := failed[u.NmID]; isFailed {
+			recordUploadAttempt(db, u, uploadID, false, errMsg)
+		} else {
+			recordUploadAttempt(db, u, uploadID, true, "")
+		}
+	}
+	return nil
+}
+
+func submitUploadTask(ctx context.Context, apiKey string, reqBody wbUploadTaskRequest) (int64, error) {
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wbUploadTaskURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка запроса загрузки цен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("WB вернул статус %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response wbUploadTaskResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("ошибка разбора ответа загрузки цен: %w", err)
+	}
+	return response.Data.UploadID, nil
+}
+
+func pollUploadStatus(ctx context.Context, apiKey string, uploadID int64, interval time.Duration, attempts int) (*wbUploadStatusResponse, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	url := fmt.Sprintf("%s?uploadID=%d", wbUploadStatusURL, uploadID)
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", apiKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка запроса статуса задачи %d: %w", uploadID, err)
+		}
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		var status wbUploadStatusResponse
+		if err := json.Unmarshal(body, &status); err != nil {
+			return nil, fmt.Errorf("ошибка разбора статуса задачи %d: %w", uploadID, err)
+		}
+
+		switch status.Data.Status {
+		case "done", "processed":
+			return &status, nil
+		case "processing":
+			log.Printf("Задача %d ещё в обработке, попытка %d/%d...", uploadID, attempt+1, attempts)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		case "error", "failed":
+			// WB не гарантирует, что errors перечисляет каждый затронутый nmID при отказе
+			// всей задачи, поэтому не возвращаем status как есть - это заставило бы
+			// pushBatch считать неперечисленные строки успешными. Возвращаем ошибку, чтобы
+			// вызывающий код записал неудачу по всему батчу и тот остался pending для retry.
+			return nil, fmt.Errorf("задача %d завершилась статусом %q", uploadID, status.Data.Status)
+		default:
+			return nil, fmt.Errorf("задача %d вернула неизвестный статус %q", uploadID, status.Data.Status)
+		}
+	}
+	return nil, fmt.Errorf("задача %d не завершилась за %d попыток", uploadID, attempts)
+}
+
+func recordUploadAttempt(db *sql.DB, u priceUpdate, uploadID int64, success bool, wbError string) {
+	_, err := db.Exec(`
+		INSERT INTO price_upload_attempts (nm_id, new_price, new_discount, upload_id, success, wb_error, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, u.NmID, u.NewPrice, u.NewDiscount, uploadID, success, nullableString(wbError), time.Now().UTC())
+	if err != nil {
+		log.Printf("Ошибка записи попытки выгрузки для nmID=%d: %v", u.NmID, err)
+	}
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package cargo_avto_update
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestPriceDeltaPct(t *testing.T) {
+	cases := []struct {
+		name     string
+		oldPrice float64
+		newPrice int
+		want     float64
+	}{
+		{"no change", 1000, 1000, 0},
+		{"10 percent up", 1000, 1100, 10},
+		{"10 percent down", 1000, 900, 10},
+		{"zero old price treated as full delta", 0, 500, 100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := priceDeltaPct(tc.oldPrice, tc.newPrice); got != tc.want {
+				t.Fatalf("priceDeltaPct(%v, %v) = %v, want %v", tc.oldPrice, tc.newPrice, got, tc.want)
+			}
+		})
+	}
+}
+
+func migratedTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	return db
+}
+
+func seedProduct(t *testing.T, db *sql.DB, nmID int, vendorCode string, discountedPrice float64, newPrice, newDiscount int) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO products (nm_id, vendor_code, product_id, pcs, discounted_price, new_price, new_discount)
+		VALUES (?, ?, ?, 1, ?, ?, ?)
+	`, nmID, vendorCode, vendorCode, discountedPrice, newPrice, newDiscount)
+	if err != nil {
+		t.Fatalf("failed to seed product nmID=%d: %v", nmID, err)
+	}
+}
+
+func TestPendingPriceUpdatesIncludesNeverUploaded(t *testing.T) {
+	db := migratedTestDB(t)
+	seedProduct(t, db, 1, "vc-1", 1000, 1100, 10)
+
+	pending, err := pendingPriceUpdates(db)
+	if err != nil {
+		t.Fatalf("pendingPriceUpdates failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].NmID != 1 {
+		t.Fatalf("expected a single pending update for nmID=1, got %+v", pending)
+	}
+}
+
+func TestPendingPriceUpdatesExcludesSuccessfulUnchanged(t *testing.T) {
+	db := migratedTestDB(t)
+	seedProduct(t, db, 1, "vc-1", 1000, 1100, 10)
+	recordUploadAttempt(db, priceUpdate{NmID: 1, NewPrice: 1100, NewDiscount: 10}, 42, true, "")
+
+	pending, err := pendingPriceUpdates(db)
+	if err != nil {
+		t.Fatalf("pendingPriceUpdates failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending updates after a successful attempt at the same price, got %+v", pending)
+	}
+}
+
+func TestPendingPriceUpdatesRetriesAfterFailure(t *testing.T) {
+	db := migratedTestDB(t)
+	seedProduct(t, db, 1, "vc-1", 1000, 1100, 10)
+	recordUploadAttempt(db, priceUpdate{NmID: 1, NewPrice: 1100, NewDiscount: 10}, 42, false, "wb error")
+
+	pending, err := pendingPriceUpdates(db)
+	if err != nil {
+		t.Fatalf("pendingPriceUpdates failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].NmID != 1 {
+		t.Fatalf("expected nmID=1 to remain pending after a failed attempt, got %+v", pending)
+	}
+}
+
+func TestPendingPriceUpdatesResendsWhenPriceChangedSinceLastSuccess(t *testing.T) {
+	db := migratedTestDB(t)
+	seedProduct(t, db, 1, "vc-1", 1000, 1100, 10)
+	recordUploadAttempt(db, priceUpdate{NmID: 1, NewPrice: 1050, NewDiscount: 10}, 42, true, "")
+
+	pending, err := pendingPriceUpdates(db)
+	if err != nil {
+		t.Fatalf("pendingPriceUpdates failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].NewPrice != 1100 {
+		t.Fatalf("expected nmID=1 pending with the newly calculated price, got %+v", pending)
+	}
+}
@@ -0,0 +1,150 @@
+package cargo_avto_update
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/chromedp"
+	"github.com/shopspring/decimal"
+)
+
+// ProductData - типизированный результат парсинга карточки товара у поставщика. Он не зависит
+// от конкретного сайта, поэтому pricing-движок в Process работает одинаково независимо от того,
+// какой SupplierScraper его подготовил.
+type ProductData struct {
+	Price           decimal.Decimal
+	AvailableStores int
+	Currency        string
+	Attributes      map[string]string
+}
+
+// SupplierScraper - источник данных о товаре у одного поставщика. Config передаёт набор
+// SupplierScraper'ов, что позволяет одному и тому же pricing-движку обслуживать несколько
+// сайтов поставщиков без изменений в Process.
+type SupplierScraper interface {
+	Name() string
+	Fetch(ctx context.Context, productID string) (ProductData, error)
+}
+
+// CargoAvtoScraper парсит карточку товара на sp.cargo-avto.ru через браузер (chromedp): цена и
+// наличие на этой странице рендерятся через JS, поэтому без браузера их не получить.
+type CargoAvtoScraper struct {
+	baseURL string
+}
+
+// NewCargoAvtoScraper создаёт CargoAvtoScraper. Пустой baseURL заменяется на адрес каталога
+// sp.cargo-avto.ru по умолчанию.
+func NewCargoAvtoScraper(baseURL string) *CargoAvtoScraper {
+	if baseURL == "" {
+		baseURL = defaultCargoAvtoBaseURL
+	}
+	return &CargoAvtoScraper{baseURL: baseURL}
+}
+
+func (s *CargoAvtoScraper) Name() string { return "cargo-avto" }
+
+func (s *CargoAvtoScraper) Fetch(ctx context.Context, productID string) (ProductData, error) {
+	url := s.baseURL + productID + "/"
+
+	var productPrice string
+	var availableStoresCount int
+
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(url),
+		chromedp.Sleep(2*time.Second),
+		chromedp.Click(`li.tabs-item a[href="#samovivoz-tabs"]`, chromedp.ByQuery),
+		chromedp.Sleep(2*time.Second),
+		chromedp.Text(`li[data-min="1"] .price-val`, &productPrice, chromedp.ByQuery),
+		chromedp.Evaluate(`document.querySelectorAll('.avail-item-status.avail').length`, &availableStoresCount),
+	)
+	if err != nil {
+		return ProductData{}, fmt.Errorf("ошибка парсинга страницы %s: %w", url, err)
+	}
+
+	productPrice = strings.TrimSpace(productPrice)
+	productPrice = strings.ReplaceAll(productPrice, "p", "")
+	productPrice = strings.ReplaceAll(productPrice, " ", "")
+
+	price, err := decimal.NewFromString(productPrice)
+	if err != nil {
+		return ProductData{}, fmt.Errorf("ошибка преобразования цены %q на странице %s: %w", productPrice, url, err)
+	}
+
+	return ProductData{
+		Price:           price,
+		AvailableStores: availableStoresCount,
+		Currency:        "RUB",
+		Attributes:      map[string]string{},
+	}, nil
+}
+
+// HTTPScraper получает данные о товаре обычным HTTP-запросом и разбором HTML через goquery, без
+// браузера — подходит для сайтов поставщиков, где цена и наличие не требуют выполнения JS, и
+// работает заметно быстрее CargoAvtoScraper.
+type HTTPScraper struct {
+	name          string
+	baseURL       string
+	client        *http.Client
+	priceSelector string
+	stockSelector string
+}
+
+// NewHTTPScraper создаёт HTTPScraper для сайта поставщика name по адресу baseURL. priceSelector
+// и stockSelector — CSS-селекторы цены и элементов наличия на карточке товара.
+func NewHTTPScraper(name, baseURL, priceSelector, stockSelector string) *HTTPScraper {
+	return &HTTPScraper{
+		name:          name,
+		baseURL:       baseURL,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		priceSelector: priceSelector,
+		stockSelector: stockSelector,
+	}
+}
+
+func (s *HTTPScraper) Name() string { return s.name }
+
+func (s *HTTPScraper) Fetch(ctx context.Context, productID string) (ProductData, error) {
+	url := s.baseURL + productID + "/"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ProductData{}, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ProductData{}, fmt.Errorf("ошибка запроса страницы %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return ProductData{}, fmt.Errorf("ошибка разбора страницы %s: %w", url, err)
+	}
+
+	priceText := strings.TrimSpace(doc.Find(s.priceSelector).First().Text())
+	priceText = strings.ReplaceAll(priceText, " ", "")
+	priceText = strings.ReplaceAll(priceText, ",", ".")
+	price, err := decimal.NewFromString(priceText)
+	if err != nil {
+		return ProductData{}, fmt.Errorf("ошибка преобразования цены %q на странице %s: %w", priceText, url, err)
+	}
+
+	return ProductData{
+		Price:           price,
+		AvailableStores: doc.Find(s.stockSelector).Length(),
+		Currency:        "RUB",
+		Attributes:      map[string]string{},
+	}, nil
+}
+
+// scaledCost переводит цену поставщика за единицу товара в стоимость cost за pcs штук,
+// округляя вверх до целого рубля цену за единицу и только потом умножая на pcs.
+func scaledCost(price decimal.Decimal, pcs int) int {
+	unit := int(price.Ceil().IntPart())
+	return unit * pcs
+}
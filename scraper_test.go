@@ -0,0 +1,78 @@
+package cargo_avto_update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestScaledCostCeilsUnitPriceBeforeMultiplying(t *testing.T) {
+	cases := []struct {
+		name  string
+		price string
+		pcs   int
+		want  int
+	}{
+		{"fractional unit price, single pack", "99.01", 1, 100},
+		{"fractional unit price, multi pack", "99.01", 3, 300},
+		{"whole unit price", "100", 4, 400},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, err := decimal.NewFromString(tc.price)
+			if err != nil {
+				t.Fatalf("failed to parse price %q: %v", tc.price, err)
+			}
+			if got := scaledCost(price, tc.pcs); got != tc.want {
+				t.Fatalf("scaledCost(%s, %d) = %d, want %d", tc.price, tc.pcs, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPScraperFetchParsesPriceAndStock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`
+			<html><body>
+				<div class="price">1 234,50</div>
+				<div class="stock-item">a</div>
+				<div class="stock-item">b</div>
+			</body></html>
+		`))
+	}))
+	defer server.Close()
+
+	scraper := NewHTTPScraper("test-supplier", server.URL+"/", ".price", ".stock-item")
+
+	data, err := scraper.Fetch(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	wantPrice := decimal.RequireFromString("1234.50")
+	if !data.Price.Equal(wantPrice) {
+		t.Fatalf("Price = %s, want %s", data.Price, wantPrice)
+	}
+	if data.AvailableStores != 2 {
+		t.Fatalf("AvailableStores = %d, want 2", data.AvailableStores)
+	}
+	if data.Currency != "RUB" {
+		t.Fatalf("Currency = %q, want RUB", data.Currency)
+	}
+}
+
+func TestHTTPScraperFetchInvalidPrice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><div class="price">нет цены</div></body></html>`))
+	}))
+	defer server.Close()
+
+	scraper := NewHTTPScraper("test-supplier", server.URL+"/", ".price", ".stock-item")
+
+	if _, err := scraper.Fetch(context.Background(), "123"); err == nil {
+		t.Fatal("expected error for unparseable price")
+	}
+}
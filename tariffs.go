@@ -0,0 +1,150 @@
+package cargo_avto_update
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultWarehouseName - имя склада, которое используется, если Config не задаёт ни
+// SelectTariff, ни WarehouseIDs, ни WarehouseFilter - сохраняет прежнее поведение.
+const defaultWarehouseName = "Маркетплейс"
+
+// WarehouseTariff - тариф FBS одного склада на определённую дату.
+type WarehouseTariff struct {
+	WarehouseID                int
+	Name                       string
+	BoxDeliveryBase            float64
+	BoxDeliveryLiter           float64
+	BoxDeliveryMarketplaceCoef float64
+}
+
+type tariffResponse struct {
+	Response struct {
+		Data struct {
+			WarehouseList []struct {
+				WarehouseID                int             `json:"warehouseID"`
+				WarehouseName              string          `json:"warehouseName"`
+				BoxDeliveryBase            json.RawMessage `json:"boxDeliveryBase"`
+				BoxDeliveryLiter           json.RawMessage `json:"boxDeliveryLiter"`
+				BoxDeliveryMarketplaceCoef json.RawMessage `json:"boxDeliveryMarketplaceCoef"`
+			} `json:"warehouseList"`
+		} `json:"data"`
+	} `json:"response"`
+}
+
+// parseFloat разбирает число тарифа, которое WB отдаёт то строкой, то числом, то с запятой
+// вместо точки в качестве десятичного разделителя.
+func parseFloat(raw json.RawMessage) (float64, error) {
+	if len(raw) == 0 {
+		return 0, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+
+	var f float64
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return 0, fmt.Errorf("неожиданный формат числа %q: %w", string(raw), err)
+	}
+	return f, nil
+}
+
+// ListFBSTariffs запрашивает тарифы FBS на указанную дату по всем складам.
+func ListFBSTariffs(apiKey, date string) ([]WarehouseTariff, error) {
+	url := fmt.Sprintf("https://common-api.wildberries.ru/api/v1/tariffs/box?date=%s", date)
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response tariffResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа тарифов FBS: %w", err)
+	}
+
+	tariffs := make([]WarehouseTariff, 0, len(response.Response.Data.WarehouseList))
+	for _, w := range response.Response.Data.WarehouseList {
+		base, err := parseFloat(w.BoxDeliveryBase)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора boxDeliveryBase склада %q: %w", w.WarehouseName, err)
+		}
+		liter, err := parseFloat(w.BoxDeliveryLiter)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора boxDeliveryLiter склада %q: %w", w.WarehouseName, err)
+		}
+		coef, err := parseFloat(w.BoxDeliveryMarketplaceCoef)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора boxDeliveryMarketplaceCoef склада %q: %w", w.WarehouseName, err)
+		}
+		tariffs = append(tariffs, WarehouseTariff{
+			WarehouseID:                w.WarehouseID,
+			Name:                       w.WarehouseName,
+			BoxDeliveryBase:            base,
+			BoxDeliveryLiter:           liter,
+			BoxDeliveryMarketplaceCoef: coef,
+		})
+	}
+	return tariffs, nil
+}
+
+// selectWarehouseTariff выбирает один тариф из списка складов по правилам Config:
+// SelectTariff, если задан, иначе первый найденный среди WarehouseIDs, иначе WarehouseFilter
+// по имени, иначе склад defaultWarehouseName - как было до появления выбора склада.
+func selectWarehouseTariff(tariffs []WarehouseTariff, cfg Config) (WarehouseTariff, error) {
+	if len(tariffs) == 0 {
+		return WarehouseTariff{}, fmt.Errorf("пустой список тарифов FBS")
+	}
+
+	if cfg.SelectTariff != nil {
+		return cfg.SelectTariff(tariffs), nil
+	}
+
+	if len(cfg.WarehouseIDs) > 0 {
+		byID := make(map[int]WarehouseTariff, len(tariffs))
+		for _, t := range tariffs {
+			byID[t.WarehouseID] = t
+		}
+		for _, id := range cfg.WarehouseIDs {
+			if t, ok := byID[id]; ok {
+				return t, nil
+			}
+		}
+		return WarehouseTariff{}, fmt.Errorf("ни один из складов %v не найден в тарифах FBS", cfg.WarehouseIDs)
+	}
+
+	name := cfg.WarehouseFilter
+	if name == "" {
+		name = defaultWarehouseName
+	}
+	for _, t := range tariffs {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return WarehouseTariff{}, fmt.Errorf("склад %q не найден в тарифах FBS", name)
+}
@@ -0,0 +1,127 @@
+package cargo_avto_update
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFloatAcceptsStringNumberAndComma(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want float64
+	}{
+		{"plain number", `12.5`, 12.5},
+		{"string with dot", `"12.5"`, 12.5},
+		{"string with comma", `"12,5"`, 12.5},
+		{"empty string", `""`, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFloat(json.RawMessage(tc.raw))
+			if err != nil {
+				t.Fatalf("parseFloat(%s) failed: %v", tc.raw, err)
+			}
+			if got != tc.want {
+				t.Fatalf("parseFloat(%s) = %v, want %v", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFloatEmptyRawIsZero(t *testing.T) {
+	got, err := parseFloat(nil)
+	if err != nil || got != 0 {
+		t.Fatalf("expected 0, nil for empty raw, got %v, %v", got, err)
+	}
+}
+
+func TestParseFloatRejectsGarbage(t *testing.T) {
+	if _, err := parseFloat(json.RawMessage(`"not-a-number"`)); err == nil {
+		t.Fatal("expected error for non-numeric string")
+	}
+}
+
+func testTariffs() []WarehouseTariff {
+	return []WarehouseTariff{
+		{WarehouseID: 1, Name: "Коледино"},
+		{WarehouseID: 2, Name: "Маркетплейс"},
+		{WarehouseID: 3, Name: "Электросталь"},
+	}
+}
+
+func TestSelectWarehouseTariffEmptyList(t *testing.T) {
+	if _, err := selectWarehouseTariff(nil, Config{}); err == nil {
+		t.Fatal("expected error for empty tariff list")
+	}
+}
+
+func TestSelectWarehouseTariffDefaultsToMarketplace(t *testing.T) {
+	got, err := selectWarehouseTariff(testTariffs(), Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.WarehouseID != 2 {
+		t.Fatalf("expected default warehouse %q, got %+v", defaultWarehouseName, got)
+	}
+}
+
+func TestSelectWarehouseTariffByWarehouseFilter(t *testing.T) {
+	got, err := selectWarehouseTariff(testTariffs(), Config{WarehouseFilter: "Электросталь"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.WarehouseID != 3 {
+		t.Fatalf("expected warehouse 3, got %+v", got)
+	}
+}
+
+func TestSelectWarehouseTariffByWarehouseIDsTakesFirstFound(t *testing.T) {
+	got, err := selectWarehouseTariff(testTariffs(), Config{WarehouseIDs: []int{99, 3, 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.WarehouseID != 3 {
+		t.Fatalf("expected first matching warehouse 3, got %+v", got)
+	}
+}
+
+func TestSelectWarehouseTariffWarehouseIDsTakePriorityOverFilter(t *testing.T) {
+	cfg := Config{WarehouseIDs: []int{1}, WarehouseFilter: "Электросталь"}
+	got, err := selectWarehouseTariff(testTariffs(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.WarehouseID != 1 {
+		t.Fatalf("expected WarehouseIDs to take priority over WarehouseFilter, got %+v", got)
+	}
+}
+
+func TestSelectWarehouseTariffSelectTariffTakesPriorityOverEverything(t *testing.T) {
+	cfg := Config{
+		WarehouseIDs:    []int{1},
+		WarehouseFilter: "Электросталь",
+		SelectTariff: func(tariffs []WarehouseTariff) WarehouseTariff {
+			return tariffs[len(tariffs)-1]
+		},
+	}
+	got, err := selectWarehouseTariff(testTariffs(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.WarehouseID != 3 {
+		t.Fatalf("expected SelectTariff to take priority, got %+v", got)
+	}
+}
+
+func TestSelectWarehouseTariffWarehouseIDsNotFound(t *testing.T) {
+	if _, err := selectWarehouseTariff(testTariffs(), Config{WarehouseIDs: []int{99}}); err == nil {
+		t.Fatal("expected error when none of WarehouseIDs are present in the tariff list")
+	}
+}
+
+func TestSelectWarehouseTariffWarehouseFilterNotFound(t *testing.T) {
+	if _, err := selectWarehouseTariff(testTariffs(), Config{WarehouseFilter: "Несуществующий"}); err == nil {
+		t.Fatal("expected error when WarehouseFilter matches no warehouse")
+	}
+}